@@ -0,0 +1,181 @@
+package dht
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bytom/event"
+	"github.com/bytom/p2p/discover"
+)
+
+const (
+	logModule           = "p2p/dht"
+	refreshCycle        = 1 * time.Hour
+	bootstrapCycle      = 5 * time.Minute
+	minRoutingTableSize = 4 // re-seed from bootstrap nodes once the table drops below this size
+)
+
+// PeerEvent represents a peer discovered through the DHT, mirroring
+// mdns.LANPeerEvent so the p2p switch can treat both discovery mechanisms
+// the same way.
+type PeerEvent struct {
+	IP   []net.IP
+	Port int
+}
+
+// dhtProtocol abstracts the Kademlia wire operations so the routing table
+// and bootstrap logic can be driven by something other than a real UDP
+// socket (e.g. in tests).
+type dhtProtocol interface {
+	findNode(target Node, targetID NodeID) ([]Node, error)
+	ping(target Node) error
+}
+
+// DHTDiscover discovers WAN peers through a Kademlia distributed hash
+// table seeded from a set of bootstrap nodes. It implements the same
+// Subscribe/Stop shape as mdns.LANDiscover so the p2p switch can use LAN
+// and WAN discovery interchangeably.
+type DHTDiscover struct {
+	protocol        dhtProtocol
+	table           *table
+	bootstrapNodes  []Node
+	entries         chan PeerEvent
+	eventDispatcher *event.Dispatcher
+	quite           chan struct{}
+	wg              sync.WaitGroup
+}
+
+var _ discover.Discoverer = (*DHTDiscover)(nil)
+
+// NewDHTDiscover creates a new DHT-based WAN peer discover. self is this
+// node's ID in the DHT key space; bootstrapNodes seed the routing table on
+// startup and whenever it falls below minRoutingTableSize entries.
+func NewDHTDiscover(protocol dhtProtocol, self NodeID, bootstrapNodes []Node) (*DHTDiscover, error) {
+	dd := &DHTDiscover{
+		protocol:        protocol,
+		table:           newTable(self),
+		bootstrapNodes:  bootstrapNodes,
+		entries:         make(chan PeerEvent, 1024),
+		eventDispatcher: event.NewDispatcher(),
+		quite:           make(chan struct{}),
+	}
+
+	dd.wg.Add(3)
+	go dd.bootstrapRoutine()
+	go dd.refreshRoutine()
+	go dd.getDHTPeerLoop()
+	return dd, nil
+}
+
+// Stop cancels the DHT discover and waits for every background goroutine
+// to exit before returning.
+func (dd *DHTDiscover) Stop() {
+	close(dd.quite)
+	dd.wg.Wait()
+	dd.eventDispatcher.Stop()
+}
+
+// Subscribe used to subscribe for PeerEvent.
+func (dd *DHTDiscover) Subscribe() (*event.Subscription, error) {
+	return dd.eventDispatcher.Subscribe(PeerEvent{})
+}
+
+// bootstrapRoutine seeds the routing table from the configured bootstrap
+// nodes on startup, and re-seeds it whenever it falls below
+// minRoutingTableSize so the node stays reachable after bootstrap nodes
+// drop off or the table empties out.
+func (dd *DHTDiscover) bootstrapRoutine() {
+	defer dd.wg.Done()
+
+	ticker := time.NewTicker(bootstrapCycle)
+	defer ticker.Stop()
+
+	dd.bootstrap()
+	for {
+		select {
+		case <-ticker.C:
+			if dd.table.size() < minRoutingTableSize {
+				dd.bootstrap()
+			}
+		case <-dd.quite:
+			return
+		}
+	}
+}
+
+func (dd *DHTDiscover) bootstrap() {
+	for _, node := range dd.bootstrapNodes {
+		if err := dd.protocol.ping(node); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "err": err, "node": node.ID}).Error("dht bootstrap ping error")
+			continue
+		}
+		dd.table.addNode(node)
+		dd.lookup(node, dd.table.self)
+	}
+}
+
+// refreshRoutine periodically performs a FIND_NODE lookup against a random
+// ID in each bucket's range, keeping buckets for distant, less-used parts
+// of the key space populated.
+func (dd *DHTDiscover) refreshRoutine() {
+	defer dd.wg.Done()
+
+	ticker := time.NewTicker(refreshCycle)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			dd.refreshBuckets()
+		case <-dd.quite:
+			return
+		}
+	}
+}
+
+func (dd *DHTDiscover) refreshBuckets() {
+	for _, idx := range dd.table.bucketIndexes() {
+		target := randomIDInBucket(dd.table.self, idx)
+		for _, node := range dd.table.closest(target, 1) {
+			dd.lookup(node, target)
+		}
+	}
+}
+
+// lookup performs a single FIND_NODE RPC against node for targetID, adding
+// any returned nodes to the routing table and forwarding them to
+// subscribers as PeerEvent.
+func (dd *DHTDiscover) lookup(node Node, targetID NodeID) {
+	found, err := dd.protocol.findNode(node, targetID)
+	if err != nil {
+		log.WithFields(log.Fields{"module": logModule, "err": err, "node": node.ID}).Error("dht findNode error")
+		return
+	}
+
+	for _, n := range found {
+		dd.table.addNode(n)
+		select {
+		case dd.entries <- PeerEvent{IP: []net.IP{n.IP}, Port: n.Port}:
+		case <-dd.quite:
+			return
+		}
+	}
+}
+
+// obtain the discovered peer event and distribute it to the subscriber.
+func (dd *DHTDiscover) getDHTPeerLoop() {
+	defer dd.wg.Done()
+
+	for {
+		select {
+		case entry := <-dd.entries:
+			if err := dd.eventDispatcher.Post(entry); err != nil {
+				log.WithFields(log.Fields{"module": logModule, "err": err}).Error("event dispatch error")
+			}
+		case <-dd.quite:
+			return
+		}
+	}
+}
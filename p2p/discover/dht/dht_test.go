@@ -0,0 +1,150 @@
+package dht
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTimeout = 2 * time.Second
+
+// fakeProtocol is a dhtProtocol driven entirely in memory, so DHTDiscover's
+// bootstrap/lookup/event-delivery logic can be tested without a real UDP
+// socket.
+type fakeProtocol struct {
+	mu      sync.Mutex
+	pinged  []Node
+	lookups []struct {
+		target   Node
+		targetID NodeID
+	}
+	findNodeFn func(target Node, targetID NodeID) ([]Node, error)
+}
+
+func (f *fakeProtocol) ping(target Node) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pinged = append(f.pinged, target)
+	return nil
+}
+
+func (f *fakeProtocol) findNode(target Node, targetID NodeID) ([]Node, error) {
+	f.mu.Lock()
+	f.lookups = append(f.lookups, struct {
+		target   Node
+		targetID NodeID
+	}{target, targetID})
+	fn := f.findNodeFn
+	f.mu.Unlock()
+
+	if fn != nil {
+		return fn(target, targetID)
+	}
+	return nil, nil
+}
+
+func (f *fakeProtocol) lookupTargets() []NodeID {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := make([]NodeID, len(f.lookups))
+	for i, l := range f.lookups {
+		ids[i] = l.targetID
+	}
+	return ids
+}
+
+func TestDHTDiscoverBootstrapDelivers(t *testing.T) {
+	bootstrapNode := Node{ID: nodeID(1), IP: net.ParseIP("127.0.0.1"), Port: 1}
+	found := Node{ID: nodeID(2), IP: net.ParseIP("127.0.0.2"), Port: 46656}
+
+	// The bootstrap lookup starts inside NewDHTDiscover, and the dispatcher
+	// only delivers to subscriptions that exist when an event is posted, so
+	// hold the lookup until the test has subscribed.
+	subscribed := make(chan struct{})
+	protocol := &fakeProtocol{
+		findNodeFn: func(target Node, targetID NodeID) ([]Node, error) {
+			<-subscribed
+			return []Node{found}, nil
+		},
+	}
+
+	self := nodeID(0)
+	dd, err := NewDHTDiscover(protocol, self, []Node{bootstrapNode})
+	if err != nil {
+		t.Fatalf("NewDHTDiscover: %v", err)
+	}
+	defer dd.Stop()
+
+	sub, err := dd.Subscribe()
+	close(subscribed)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case ev := <-sub.Chan():
+		peer, ok := ev.(PeerEvent)
+		if !ok {
+			t.Fatalf("got event of type %T, want PeerEvent", ev)
+		}
+		if peer.Port != found.Port {
+			t.Errorf("PeerEvent.Port = %d, want %d", peer.Port, found.Port)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("timed out waiting for PeerEvent")
+	}
+}
+
+// TestDHTBootstrapLooksUpSelf verifies bootstrap performs FIND_NODE(self)
+// against each bootstrap node, not FIND_NODE(bootstrap node's own ID).
+func TestDHTBootstrapLooksUpSelf(t *testing.T) {
+	bootstrapNode := Node{ID: nodeID(1), IP: net.ParseIP("127.0.0.1"), Port: 1}
+	protocol := &fakeProtocol{}
+
+	self := nodeID(0)
+	dd, err := NewDHTDiscover(protocol, self, []Node{bootstrapNode})
+	if err != nil {
+		t.Fatalf("NewDHTDiscover: %v", err)
+	}
+	defer dd.Stop()
+
+	deadline := time.After(testTimeout)
+	for {
+		if targets := protocol.lookupTargets(); len(targets) > 0 {
+			if targets[0] != self {
+				t.Errorf("bootstrap looked up %v, want self %v", targets[0], self)
+			}
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for bootstrap to call findNode")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// TestDHTDiscoverStopReturns verifies Stop returns in time, i.e. that none
+// of the background goroutines it waits for keeps it from returning.
+func TestDHTDiscoverStopReturns(t *testing.T) {
+	protocol := &fakeProtocol{}
+	dd, err := NewDHTDiscover(protocol, nodeID(0), nil)
+	if err != nil {
+		t.Fatalf("NewDHTDiscover: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		dd.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("Stop did not return in time")
+	}
+}
@@ -0,0 +1,158 @@
+package dht
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+	"sort"
+	"sync"
+)
+
+const (
+	idBits     = 256 // NodeID is a 32-byte (bytom node ID sized) key
+	numBuckets = idBits
+	bucketSize = 16 // k-bucket size, matches Kademlia's k
+)
+
+// NodeID identifies a node in the DHT key space. It is derived the same
+// way as a bytom node ID, the hash of the node's public key.
+type NodeID [32]byte
+
+// Node is a single routing table entry: a NodeID paired with the network
+// address it was last seen at.
+type Node struct {
+	ID   NodeID
+	IP   net.IP
+	Port int
+}
+
+// xorDistance returns the Kademlia XOR metric distance between two node IDs.
+func xorDistance(a, b NodeID) NodeID {
+	var d NodeID
+	for i := range a {
+		d[i] = a[i] ^ b[i]
+	}
+	return d
+}
+
+// bucketIndex returns the index of the bucket a node at the given distance
+// from the local node falls into: the position of the most significant set
+// bit of the XOR distance.
+func bucketIndex(distance NodeID) int {
+	for i, b := range distance {
+		if b == 0 {
+			continue
+		}
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>uint(bit)) != 0 {
+				return i*8 + bit
+			}
+		}
+	}
+	return numBuckets - 1
+}
+
+// bucket holds the up-to-bucketSize nodes known at a given XOR-distance
+// range from the local node, ordered least-recently-seen first.
+type bucket struct {
+	nodes []Node
+}
+
+func (b *bucket) add(n Node) {
+	for i, existing := range b.nodes {
+		if existing.ID == n.ID {
+			b.nodes = append(append(b.nodes[:i], b.nodes[i+1:]...), n)
+			return
+		}
+	}
+	if len(b.nodes) >= bucketSize {
+		b.nodes = b.nodes[1:]
+	}
+	b.nodes = append(b.nodes, n)
+}
+
+// table is the Kademlia routing table: numBuckets buckets indexed by the
+// XOR distance from the local node ID.
+type table struct {
+	mu      sync.Mutex
+	self    NodeID
+	buckets [numBuckets]*bucket
+}
+
+func newTable(self NodeID) *table {
+	t := &table{self: self}
+	for i := range t.buckets {
+		t.buckets[i] = &bucket{}
+	}
+	return t
+}
+
+// addNode inserts or refreshes n in the bucket its distance from self maps to.
+func (t *table) addNode(n Node) {
+	if n.ID == t.self {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buckets[bucketIndex(xorDistance(t.self, n.ID))].add(n)
+}
+
+// closest returns at most count nodes ordered by ascending XOR distance to target.
+func (t *table) closest(target NodeID, count int) []Node {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var all []Node
+	for _, b := range t.buckets {
+		all = append(all, b.nodes...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		di, dj := xorDistance(target, all[i].ID), xorDistance(target, all[j].ID)
+		return bytes.Compare(di[:], dj[:]) < 0
+	})
+	if len(all) > count {
+		all = all[:count]
+	}
+	return all
+}
+
+// size returns the total number of nodes currently held across all buckets.
+func (t *table) size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, b := range t.buckets {
+		n += len(b.nodes)
+	}
+	return n
+}
+
+// bucketIndexes lists every bucket index, used to drive the periodic
+// refresh of each bucket in turn.
+func (t *table) bucketIndexes() []int {
+	idx := make([]int, numBuckets)
+	for i := range idx {
+		idx[i] = i
+	}
+	return idx
+}
+
+// randomIDInBucket returns a random NodeID that falls within bucket idx of
+// self: it shares self's bits above idx, differs from self at bit idx, and
+// is randomized below idx.
+func randomIDInBucket(self NodeID, idx int) NodeID {
+	id := self
+	byteIdx, bitIdx := idx/8, idx%8
+	mask := byte(0x80 >> uint(bitIdx))
+	id[byteIdx] ^= mask
+
+	rand.Read(id[byteIdx+1:])
+	if lowMask := mask - 1; lowMask != 0 {
+		var r [1]byte
+		rand.Read(r[:])
+		id[byteIdx] = (id[byteIdx] &^ lowMask) | (r[0] & lowMask)
+	}
+	return id
+}
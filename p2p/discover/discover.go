@@ -0,0 +1,20 @@
+// Package discover defines the common contract shared by this node's peer
+// discovery subsystems (LAN mDNS, WAN DHT, ...).
+package discover
+
+import "github.com/bytom/event"
+
+// Discoverer is implemented by every peer discovery subsystem so the p2p
+// switch can consume them uniformly regardless of how peers are actually
+// found (mDNS on the LAN, a DHT on the WAN, ...). Subscribers receive
+// whatever peer event type the underlying subsystem posts through its
+// event.Dispatcher.
+type Discoverer interface {
+	// Subscribe registers the caller for peer events. The concrete event
+	// type posted is subsystem-specific (e.g. mdns.LANPeerEvent,
+	// dht.PeerEvent).
+	Subscribe() (*event.Subscription, error)
+
+	// Stop tears down the discoverer and stops delivering events.
+	Stop()
+}
@@ -0,0 +1,30 @@
+package mdns
+
+import "time"
+
+// Clock abstracts the passage of time used by registerServiceRoutine so
+// that its initial delay and periodic re-registration can be driven
+// deterministically in tests instead of by the wall clock.
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of *time.Ticker a Clock implementation hands back.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the production Clock, backed directly by the time package.
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return &realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r *realTicker) C() <-chan time.Time { return r.t.C }
+
+func (r *realTicker) Stop() { r.t.Stop() }
@@ -0,0 +1,60 @@
+package mdns
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BuildTXT returns the TXT record map NewLANDiscover should advertise so
+// peers can be gated on network, node identity and protocol version before
+// a handshake is ever attempted.
+func BuildTXT(network, nodeID, version string) map[string]string {
+	return map[string]string{
+		txtKeyNetwork: network,
+		txtKeyNodeID:  nodeID,
+		txtKeyVersion: version,
+	}
+}
+
+// NewCompatibilityFilter returns a PeerFilter that keeps a discovered peer
+// only if it advertises the same network, a different nodeID than
+// ownNodeID (so a node never connects to itself), and, when minVersion is
+// non-empty, a version no older than minVersion. This is the default gate
+// described for mDNS LAN discovery: two bytomd instances on different
+// chains (or a node rediscovering itself) never produce a LANPeerEvent.
+func NewCompatibilityFilter(network, ownNodeID, minVersion string) PeerFilter {
+	return func(txt map[string]string) bool {
+		if txt[txtKeyNetwork] != network {
+			return false
+		}
+		if txt[txtKeyNodeID] == ownNodeID {
+			return false
+		}
+		if minVersion != "" && semverLess(txt[txtKeyVersion], minVersion) {
+			return false
+		}
+		return true
+	}
+}
+
+// semverLess reports whether a is an older major.minor.patch version than
+// b. It's a best-effort numeric comparison, not a strict semver parser:
+// components are compared left to right, and the first one that is not a
+// number in either version ends the comparison as "not less", so a version
+// is never filtered out just because a component couldn't be parsed. The
+// numeric components before that still decide ("1.0.x" is less than
+// "1.2.0").
+func semverLess(a, b string) bool {
+	ap, bp := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		an, aerr := strconv.Atoi(ap[i])
+		bn, berr := strconv.Atoi(bp[i])
+		if aerr != nil || berr != nil {
+			return false
+		}
+		if an != bn {
+			return an < bn
+		}
+	}
+	return false
+}
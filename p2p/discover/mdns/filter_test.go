@@ -0,0 +1,63 @@
+package mdns
+
+import "testing"
+
+func TestCompatibilityFilter(t *testing.T) {
+	filter := NewCompatibilityFilter("mainnet", "self-node-id", "1.2.0")
+
+	cases := []struct {
+		name string
+		txt  map[string]string
+		want bool
+	}{
+		{
+			name: "compatible peer",
+			txt:  map[string]string{txtKeyNetwork: "mainnet", txtKeyNodeID: "peer-node-id", txtKeyVersion: "1.2.1"},
+			want: true,
+		},
+		{
+			name: "different network",
+			txt:  map[string]string{txtKeyNetwork: "testnet", txtKeyNodeID: "peer-node-id", txtKeyVersion: "1.2.1"},
+			want: false,
+		},
+		{
+			name: "self rediscovery",
+			txt:  map[string]string{txtKeyNetwork: "mainnet", txtKeyNodeID: "self-node-id", txtKeyVersion: "1.2.1"},
+			want: false,
+		},
+		{
+			name: "older version",
+			txt:  map[string]string{txtKeyNetwork: "mainnet", txtKeyNodeID: "peer-node-id", txtKeyVersion: "1.1.9"},
+			want: false,
+		},
+		{
+			name: "same version",
+			txt:  map[string]string{txtKeyNetwork: "mainnet", txtKeyNodeID: "peer-node-id", txtKeyVersion: "1.2.0"},
+			want: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := filter(c.txt); got != c.want {
+				t.Errorf("filter(%v) = %v, want %v", c.txt, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompatibilityFilterNoMinVersion(t *testing.T) {
+	filter := NewCompatibilityFilter("mainnet", "self-node-id", "")
+
+	txt := map[string]string{txtKeyNetwork: "mainnet", txtKeyNodeID: "peer-node-id", txtKeyVersion: "0.0.1"}
+	if !filter(txt) {
+		t.Errorf("filter should keep an old version when minVersion is empty")
+	}
+}
+
+func TestBuildTXT(t *testing.T) {
+	txt := BuildTXT("mainnet", "self-node-id", "1.2.0")
+	if txt[txtKeyNetwork] != "mainnet" || txt[txtKeyNodeID] != "self-node-id" || txt[txtKeyVersion] != "1.2.0" {
+		t.Errorf("BuildTXT returned unexpected map: %v", txt)
+	}
+}
@@ -1,75 +1,150 @@
 package mdns
 
 import (
+	"context"
 	"net"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
-	"fmt"
 	"github.com/bytom/event"
+	"github.com/bytom/p2p/discover"
 )
 
+// LANDiscover implements the discover.Discoverer interface so the p2p
+// switch can treat LAN discovery the same way as other discovery
+// subsystems (e.g. the DHT-based WAN discoverer).
+var _ discover.Discoverer = (*LANDiscover)(nil)
+
 const (
 	logModule            = "p2p/mdns"
 	instanceName         = "bytomd"
-	serviceName          = "lanDiscover"
 	domainName           = "local"
 	registerServiceCycle = 10 * time.Minute
 	registerServiceDelay = 0 * time.Second
+
+	// TXT record keys advertised alongside the service so peers can be
+	// filtered before a handshake is ever attempted.
+	txtKeyNetwork = "network"
+	txtKeyNodeID  = "nodeID"
+	txtKeyVersion = "version"
 )
 
+// AddressFamily selects which IP address families a LANDiscover binds to.
+type AddressFamily int
+
+// The address families a LANDiscover can be restricted to. AddressFamilyBoth
+// is the zero value so a zero AddressFamily keeps the historical
+// bind-everything behavior.
+const (
+	AddressFamilyBoth AddressFamily = iota
+	AddressFamilyIPv4Only
+	AddressFamilyIPv6Only
+)
+
+// ServiceSpec is a single DNS-SD service a LANDiscover advertises and
+// resolves, e.g. the base p2p port or an auxiliary RPC, block explorer or
+// SPV endpoint running on the same node.
+type ServiceSpec struct {
+	Name string
+	Port int
+	TXT  map[string]string
+}
+
 // LANPeerEvent represent LAN peer ip and port.
 type LANPeerEvent struct {
-	IP   []net.IP
-	Port int
+	IP      []net.IP
+	Port    int
+	TXT     map[string]string
+	Service string // name of the ServiceSpec that produced this event
 }
 
-// mDNSProtocol mdns protocol interface.
-type mDNSProtocol interface {
-	registerService(instance string, service string, domain string, port int) error
-	registerResolver(event chan LANPeerEvent, service string, domain string) error
-	stopService()
-	stopResolver()
+// PeerFilter decides whether a discovered LANPeerEvent should be dispatched
+// to subscribers. It receives the TXT record map parsed from the DNS-SD
+// entry and returns true to keep the peer, false to drop it. A nil filter
+// keeps every discovered peer.
+type PeerFilter func(txt map[string]string) bool
+
+// Protocol is the mdns protocol interface. Every method is handed the
+// LANDiscover's context and must return once it is done; there are no
+// separate stop methods because cancelling ctx is how callers ask the
+// protocol to stop. It is exported so alternate implementations (e.g. the
+// mdns/simtest simulation harness) can be constructed outside this package.
+//
+// ifaces restricts which network interfaces the protocol binds to (nil
+// means all interfaces), and family restricts which address families are
+// used on them.
+type Protocol interface {
+	RegisterService(ctx context.Context, instance string, service ServiceSpec, domain string, ifaces []net.Interface, family AddressFamily) error
+	RegisterResolver(ctx context.Context, event chan LANPeerEvent, service string, domain string, ifaces []net.Interface, family AddressFamily) error
 }
 
 // LANDiscover responsible for finding the related services registered LAN nodes.
 type LANDiscover struct {
-	protocol        mDNSProtocol
+	protocol        Protocol
 	resolving       uint32
 	instance        string //instance name
-	service         string //service name
-	domain          string //domain name
-	servicePort     int    //service port
+	services        []ServiceSpec
+	domain          string          //domain name
+	ifaces          []net.Interface // interfaces to bind; nil means all
+	family          AddressFamily
+	filter          PeerFilter
 	entries         chan LANPeerEvent
 	eventDispatcher *event.Dispatcher
-	quite           chan struct{}
+	clock           Clock
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
 }
 
-// NewLANDiscover create a new LAN node discover.
-func NewLANDiscover(protocol mDNSProtocol, port int) (*LANDiscover, error) {
+// NewLANDiscover create a new LAN node discover. The returned LANDiscover's
+// background goroutines run until ctx is done or Stop is called. services
+// lists every DNS-SD service to advertise and resolve (the base p2p port
+// plus any auxiliary RPC/explorer/SPV services); ifaces restricts which
+// network interfaces are bound (nil binds all interfaces) and family
+// restricts which IP address families are used on them. filter, if
+// non-nil, is consulted for every discovered peer so that incompatible
+// peers (different network, own nodeID, unsupported version) are dropped
+// before a LANPeerEvent is ever dispatched.
+func NewLANDiscover(ctx context.Context, protocol Protocol, services []ServiceSpec, ifaces []net.Interface, family AddressFamily, filter PeerFilter) (*LANDiscover, error) {
+	return NewLANDiscoverWithClock(ctx, protocol, services, ifaces, family, filter, realClock{})
+}
+
+// NewLANDiscoverWithClock is NewLANDiscover with an injectable Clock, so
+// tests (e.g. the mdns/simtest harness) can control the timing of service
+// re-registration deterministically instead of waiting on the wall clock.
+func NewLANDiscoverWithClock(ctx context.Context, protocol Protocol, services []ServiceSpec, ifaces []net.Interface, family AddressFamily, filter PeerFilter, clock Clock) (*LANDiscover, error) {
+	ctx, cancel := context.WithCancel(ctx)
 	ld := &LANDiscover{
 		protocol:        protocol,
 		instance:        instanceName,
-		service:         serviceName,
+		services:        services,
 		domain:          domainName,
-		servicePort:     port,
+		ifaces:          ifaces,
+		family:          family,
+		filter:          filter,
 		entries:         make(chan LANPeerEvent, 1024),
 		eventDispatcher: event.NewDispatcher(),
-		quite:           make(chan struct{}),
+		clock:           clock,
+		ctx:             ctx,
+		cancel:          cancel,
 	}
-	// register service
-	go ld.registerServiceRoutine()
+
+	ld.wg.Add(2)
+	ready := make(chan struct{})
+	go ld.registerServiceRoutine(ready)
 	go ld.getLANPeerLoop()
+	<-ready // don't return until the initial clock wait is registered, so a caller using a manual Clock can Advance() safely right away
 	return ld, nil
 }
 
-// Stop stop LAN discover.
+// Stop cancels the LANDiscover's context and waits for every background
+// goroutine to exit before returning.
 func (ld *LANDiscover) Stop() {
-	close(ld.quite)
-	ld.protocol.stopService()
-	ld.protocol.stopResolver()
+	ld.cancel()
+	ld.wg.Wait()
 	ld.eventDispatcher.Stop()
 }
 
@@ -81,10 +156,13 @@ func (ld *LANDiscover) Subscribe() (*event.Subscription, error) {
 		return nil, err
 	}
 
-	//need to register the parser once.
+	//need to register the resolvers once, one per advertised service so
+	//subscribers can tell events apart by LANPeerEvent.Service.
 	if atomic.CompareAndSwapUint32(&ld.resolving, 0, 1) {
-		if err = ld.protocol.registerResolver(ld.entries, ld.service, ld.domain); err != nil {
-			return nil, err
+		for _, service := range ld.services {
+			if err = ld.protocol.RegisterResolver(ld.ctx, ld.entries, service.Name, ld.domain, ld.ifaces, ld.family); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -92,42 +170,67 @@ func (ld *LANDiscover) Subscribe() (*event.Subscription, error) {
 }
 
 // register service routine, will be re-registered periodically
-// for the stability of node discovery.
-func (ld *LANDiscover) registerServiceRoutine() {
-	time.Sleep(registerServiceDelay)
-	err := ld.protocol.registerService(ld.instance, ld.service, ld.domain, ld.servicePort)
-	if err != nil {
-		log.WithFields(log.Fields{"module": logModule, "err": err}).Error("mdns service register error")
+// for the stability of node discovery. ready is closed once the initial
+// clock wait has been registered, so NewLANDiscoverWithClock can block
+// until it is safe for a caller to advance a manual Clock.
+func (ld *LANDiscover) registerServiceRoutine(ready chan struct{}) {
+	defer ld.wg.Done()
+
+	delay := ld.clock.After(registerServiceDelay)
+	close(ready)
+
+	select {
+	case <-delay:
+	case <-ld.ctx.Done():
+		return
+	}
+
+	if !ld.registerServices() {
 		return
 	}
 
-	ticker := time.NewTicker(registerServiceCycle)
+	ticker := ld.clock.NewTicker(registerServiceCycle)
 	defer ticker.Stop()
 	for {
 		select {
-		case <-ticker.C:
-			ld.protocol.stopService()
-			if err := ld.protocol.registerService(ld.instance, ld.service, ld.domain, ld.servicePort); err != nil {
-				log.WithFields(log.Fields{"module": logModule, "err": err}).Error("mdns service register error")
+		case <-ticker.C():
+			if !ld.registerServices() {
 				return
 			}
-		case <-ld.quite:
+		case <-ld.ctx.Done():
 			return
 		}
 	}
 }
 
+// registerServices (re-)registers every configured ServiceSpec, logging
+// and giving up on the first error.
+func (ld *LANDiscover) registerServices() bool {
+	for _, service := range ld.services {
+		if err := ld.protocol.RegisterService(ld.ctx, ld.instance, service, ld.domain, ld.ifaces, ld.family); err != nil {
+			log.WithFields(log.Fields{"module": logModule, "service": service.Name, "err": err}).Error("mdns service register error")
+			return false
+		}
+	}
+	return true
+}
+
 // obtain the lan peer event from the specific protocol
 // and distribute it to the subscriber.
 func (ld *LANDiscover) getLANPeerLoop() {
+	defer ld.wg.Done()
+
 	for {
 		select {
 		case entry := <-ld.entries:
-			fmt.Println("===", entry)
+			if ld.filter != nil && !ld.filter(entry.TXT) {
+				continue
+			}
+
 			if err := ld.eventDispatcher.Post(entry); err != nil {
 				log.WithFields(log.Fields{"module": logModule, "err": err}).Error("event dispatch error")
 			}
-		case <-ld.quite:
+		case <-ld.ctx.Done():
 			return
 		}
 	}
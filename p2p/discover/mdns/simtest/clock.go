@@ -0,0 +1,98 @@
+package simtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+// ManualClock is an mdns.Clock whose time only moves forward when Advance
+// is called, giving tests full control over when registerServiceRoutine's
+// delay elapses and its re-registration ticker fires.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+	tickers []*manualTicker
+}
+
+type waiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// NewManualClock creates a ManualClock starting at an arbitrary fixed time.
+func NewManualClock() *ManualClock {
+	return &ManualClock{now: time.Unix(0, 0)}
+}
+
+var _ mdns.Clock = (*ManualClock)(nil)
+
+// After implements mdns.Clock.
+func (c *ManualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, waiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// NewTicker implements mdns.Clock.
+func (c *ManualClock) NewTicker(d time.Duration) mdns.Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &manualTicker{clock: c, period: d, next: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, firing any waiter or ticker whose
+// deadline has elapsed.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.deadline.After(c.now) {
+			w.ch <- c.now
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	c.waiters = remaining
+
+	for _, t := range c.tickers {
+		if t.stopped {
+			continue
+		}
+		for !t.next.After(c.now) {
+			select {
+			case t.ch <- c.now:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+type manualTicker struct {
+	clock   *ManualClock
+	period  time.Duration
+	next    time.Time
+	ch      chan time.Time
+	stopped bool
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.ch }
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
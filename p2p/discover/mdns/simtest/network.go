@@ -0,0 +1,104 @@
+package simtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/bytom/event"
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+// Node is one member of a virtual LAN: a LANDiscover wired up to the
+// shared Registry through a SimulatedProtocol, plus the ManualClock
+// driving its service re-registration.
+type Node struct {
+	Instance string
+	Discover *mdns.LANDiscover
+	Clock    *ManualClock
+}
+
+// VirtualLAN is a deterministic, in-process stand-in for a physical LAN:
+// every Node shares the same Registry, so NewVirtualLAN gives contributors
+// a devp2p-style network simulator for regression-testing mDNS discovery.
+type VirtualLAN struct {
+	Registry *Registry
+	Nodes    []*Node
+}
+
+// NewVirtualLAN spins up count nodes sharing a single Registry with the
+// given loss rate and jitter bound (see NewRegistry), cancelled when ctx
+// is done. Each node advertises the same services (with its index
+// substituted for the port, so subscribers can tell peers apart in
+// tests); ifaces and family are passed straight through to LANDiscover but
+// have no effect on SimulatedProtocol, which has no real sockets to bind.
+func NewVirtualLAN(ctx context.Context, count int, lossRate float64, maxJitter time.Duration, seed int64, services []mdns.ServiceSpec, ifaces []net.Interface, family mdns.AddressFamily, filter mdns.PeerFilter) (*VirtualLAN, error) {
+	lan := &VirtualLAN{Registry: NewRegistry(lossRate, maxJitter, seed)}
+
+	for i := 0; i < count; i++ {
+		instance := fmt.Sprintf("node-%d", i)
+		clock := NewManualClock()
+		protocol := NewSimulatedProtocol(lan.Registry, instance)
+
+		nodeServices := make([]mdns.ServiceSpec, len(services))
+		for j, svc := range services {
+			nodeServices[j] = mdns.ServiceSpec{Name: svc.Name, Port: i, TXT: svc.TXT}
+		}
+
+		ld, err := mdns.NewLANDiscoverWithClock(ctx, protocol, nodeServices, ifaces, family, filter, clock)
+		if err != nil {
+			return nil, err
+		}
+
+		lan.Nodes = append(lan.Nodes, &Node{Instance: instance, Discover: ld, Clock: clock})
+	}
+
+	return lan, nil
+}
+
+// Advance moves every node's clock forward by d, driving service
+// re-registration deterministically across the whole virtual LAN.
+func (v *VirtualLAN) Advance(d time.Duration) {
+	for _, n := range v.Nodes {
+		n.Clock.Advance(d)
+	}
+}
+
+// Partition splits the virtual LAN into isolated groups identified by
+// name: nodes in different non-empty groups stop discovering each other,
+// as if a router between them dropped the mDNS multicast.
+func (v *VirtualLAN) Partition(groups map[string][]int) {
+	for name, indexes := range groups {
+		for _, idx := range indexes {
+			v.Registry.Partition(v.Nodes[idx].Instance, name)
+		}
+	}
+}
+
+// Heal removes every partition, reconnecting the whole virtual LAN.
+func (v *VirtualLAN) Heal() {
+	for _, n := range v.Nodes {
+		v.Registry.Partition(n.Instance, "")
+	}
+}
+
+// Subscribe subscribes to LANPeerEvent for every node, in node order.
+func (v *VirtualLAN) Subscribe() ([]*event.Subscription, error) {
+	subs := make([]*event.Subscription, len(v.Nodes))
+	for i, n := range v.Nodes {
+		sub, err := n.Discover.Subscribe()
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = sub
+	}
+	return subs, nil
+}
+
+// Stop stops every node's LANDiscover.
+func (v *VirtualLAN) Stop() {
+	for _, n := range v.Nodes {
+		n.Discover.Stop()
+	}
+}
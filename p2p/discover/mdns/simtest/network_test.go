@@ -0,0 +1,122 @@
+package simtest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytom/event"
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+func recvLANEvents(t *testing.T, sub *event.Subscription, n int) []mdns.LANPeerEvent {
+	t.Helper()
+
+	events := make([]mdns.LANPeerEvent, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case ev := <-sub.Chan():
+			peer, ok := ev.(mdns.LANPeerEvent)
+			if !ok {
+				t.Fatalf("event %d has type %T, want mdns.LANPeerEvent", i, ev)
+			}
+			events = append(events, peer)
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for event %d/%d, got %v so far", i+1, n, events)
+		}
+	}
+	return events
+}
+
+func assertNoLANEvent(t *testing.T, sub *event.Subscription) {
+	t.Helper()
+
+	select {
+	case ev := <-sub.Chan():
+		t.Fatalf("expected no event, got %v", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestVirtualLANDiscoveryThroughLANDiscover drives a 3-node VirtualLAN
+// through the real mdns.LANDiscover Subscribe/Advance path (not the
+// Registry directly), and asserts the exact LANPeerEvent sequence each
+// node's subscription receives. This exercises registerServiceRoutine's
+// ManualClock-driven registration and getLANPeerLoop's dispatch, including
+// the startup-race fix that gates NewLANDiscoverWithClock on the initial
+// clock wait being registered before Advance is ever called.
+func TestVirtualLANDiscoveryThroughLANDiscover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	services := []mdns.ServiceSpec{{Name: "p2p", TXT: map[string]string{"network": "mainnet"}}}
+	lan, err := NewVirtualLAN(ctx, 3, 0, 0, 1, services, nil, mdns.AddressFamilyBoth, nil)
+	if err != nil {
+		t.Fatalf("NewVirtualLAN: %v", err)
+	}
+	defer lan.Stop()
+
+	subs, err := lan.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	// Advance and drain one node at a time: registerServiceRoutine runs in
+	// its own goroutine, so nothing orders node-0's registration before
+	// node-1's beyond the order Advance is called in. Waiting for every
+	// other subscriber to receive node i's event before advancing node i+1
+	// turns that blocking receive into a barrier, making the resulting
+	// per-subscriber sequence deterministic without sleeping.
+	want := [][]int{
+		{1, 2}, // node-0 discovers node-1 then node-2
+		{0, 2}, // node-1 discovers node-0 then node-2
+		{0, 1}, // node-2 discovers node-0 then node-1
+	}
+	var got [][]int = make([][]int, len(subs))
+	for i := range lan.Nodes {
+		lan.Nodes[i].Clock.Advance(time.Second)
+		for j := range subs {
+			if j == i {
+				continue
+			}
+			e := recvLANEvents(t, subs[j], 1)[0]
+			got[j] = append(got[j], e.Port)
+			if e.Service != "p2p" {
+				t.Errorf("node-%d: event %v has Service %q, want p2p", j, e, e.Service)
+			}
+		}
+	}
+
+	for i, ports := range want {
+		if !reflect.DeepEqual(got[i], ports) {
+			t.Errorf("node-%d: got ports %v, want %v", i, got[i], ports)
+		}
+	}
+}
+
+// TestVirtualLANFilterDropsPeers verifies a PeerFilter passed to
+// NewVirtualLAN is actually consulted by LANDiscover.getLANPeerLoop, not
+// just by the filter package's own unit tests.
+func TestVirtualLANFilterDropsPeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rejectAll := func(map[string]string) bool { return false }
+	services := []mdns.ServiceSpec{{Name: "p2p", TXT: map[string]string{"network": "mainnet"}}}
+	lan, err := NewVirtualLAN(ctx, 2, 0, 0, 2, services, nil, mdns.AddressFamilyBoth, rejectAll)
+	if err != nil {
+		t.Fatalf("NewVirtualLAN: %v", err)
+	}
+	defer lan.Stop()
+
+	subs, err := lan.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	lan.Advance(time.Second)
+
+	assertNoLANEvent(t, subs[0])
+	assertNoLANEvent(t, subs[1])
+}
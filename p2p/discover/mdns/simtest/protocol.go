@@ -0,0 +1,51 @@
+package simtest
+
+import (
+	"context"
+	"net"
+
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+// SimulatedProtocol is a memory-only implementation of mdns.Protocol,
+// backed by a shared Registry instead of real multicast DNS. Every
+// LANDiscover in a virtual LAN that shares the same Registry can discover
+// the others. It has no real network interfaces to bind, so the ifaces
+// and family parameters of mdns.Protocol are accepted but ignored.
+type SimulatedProtocol struct {
+	registry *Registry
+	instance string
+}
+
+var _ mdns.Protocol = (*SimulatedProtocol)(nil)
+
+// NewSimulatedProtocol creates a SimulatedProtocol for instance, registered
+// against the shared registry. Construct one per simulated node and pass
+// it to mdns.NewLANDiscoverWithClock.
+func NewSimulatedProtocol(registry *Registry, instance string) *SimulatedProtocol {
+	return &SimulatedProtocol{registry: registry, instance: instance}
+}
+
+// RegisterService implements mdns.Protocol by publishing the service to
+// the shared registry until ctx is done. It registers under p.instance
+// rather than the instance argument LANDiscover passes in: a real mDNS
+// responder on each physical host would make every node's instance name
+// effectively unique, but a simulated LAN has no such per-host identity,
+// so the registry needs p.instance to tell simulated nodes apart and
+// filter out a node's own broadcasts.
+func (p *SimulatedProtocol) RegisterService(ctx context.Context, instance string, service mdns.ServiceSpec, domain string, ifaces []net.Interface, family mdns.AddressFamily) error {
+	p.registry.register(ctx, p.instance, service.Name, domain, service.Port, service.TXT)
+	go func() {
+		<-ctx.Done()
+		p.registry.unregister(p.instance, service.Name, domain)
+	}()
+	return nil
+}
+
+// RegisterResolver implements mdns.Protocol by subscribing entries to
+// every instance registered for service.domain on the shared registry
+// until ctx is done.
+func (p *SimulatedProtocol) RegisterResolver(ctx context.Context, entries chan mdns.LANPeerEvent, service, domain string, ifaces []net.Interface, family mdns.AddressFamily) error {
+	p.registry.resolve(ctx, p.instance, entries, service, domain)
+	return nil
+}
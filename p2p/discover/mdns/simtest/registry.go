@@ -0,0 +1,195 @@
+// Package simtest provides an in-process, deterministic simulation of
+// mdns.LANDiscover so that multi-node discovery behavior can be
+// regression-tested without touching a real network socket.
+package simtest
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+// Registry is a shared in-process bulletin board that every
+// SimulatedProtocol on a virtual LAN registers against and resolves
+// through, standing in for the multicast UDP group a real mDNS
+// implementation would use.
+type Registry struct {
+	mu        sync.Mutex
+	rand      *rand.Rand
+	lossRate  float64
+	maxJitter time.Duration
+	services  map[string]map[string]registration // "service.domain" -> instance -> registration
+	resolvers map[string][]*resolver             // "service.domain" -> subscribed resolvers
+	partition map[string]string                  // instance -> partition name; empty string means unpartitioned
+}
+
+type registration struct {
+	service string
+	port    int
+	txt     map[string]string
+}
+
+type resolver struct {
+	instance string // instance name of the resolver's owner, used for partitioning
+	ctx      context.Context
+	entries  chan mdns.LANPeerEvent
+}
+
+// NewRegistry creates a Registry. lossRate in [0,1) is the fraction of
+// deliveries dropped to simulate packet loss; maxJitter delays each
+// delivered entry by a random duration in [0, maxJitter), simulating
+// out-of-order arrival (0 delivers synchronously, which is what
+// deterministic tests asserting an exact event sequence want). seed
+// controls both the jitter and loss random sequence so a failing test run
+// is reproducible.
+func NewRegistry(lossRate float64, maxJitter time.Duration, seed int64) *Registry {
+	return &Registry{
+		rand:      rand.New(rand.NewSource(seed)),
+		lossRate:  lossRate,
+		maxJitter: maxJitter,
+		services:  map[string]map[string]registration{},
+		resolvers: map[string][]*resolver{},
+		partition: map[string]string{},
+	}
+}
+
+// Partition assigns instance to a named network partition. Instances in
+// different non-empty partitions do not discover each other; instances in
+// the empty ("") partition are reachable from everyone.
+func (r *Registry) Partition(instance, partition string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partition[instance] = partition
+}
+
+func serviceKey(service, domain string) string { return service + "." + domain }
+
+func (r *Registry) dropped() bool {
+	return r.lossRate > 0 && r.rand.Float64() < r.lossRate
+}
+
+func (r *Registry) jitter() time.Duration {
+	if r.maxJitter <= 0 {
+		return 0
+	}
+	return time.Duration(r.rand.Int63n(int64(r.maxJitter)))
+}
+
+func (r *Registry) reachable(a, b string) bool {
+	pa, pb := r.partition[a], r.partition[b]
+	return pa == "" || pb == "" || pa == pb
+}
+
+// register records instance's service in the registry and delivers a
+// LANPeerEvent to every resolver currently subscribed to it.
+func (r *Registry) register(ctx context.Context, instance, service, domain string, port int, txt map[string]string) {
+	r.mu.Lock()
+	k := serviceKey(service, domain)
+	if r.services[k] == nil {
+		r.services[k] = map[string]registration{}
+	}
+	r.services[k][instance] = registration{service: service, port: port, txt: txt}
+	resolvers := append([]*resolver{}, r.resolvers[k]...)
+	r.mu.Unlock()
+
+	for _, res := range resolvers {
+		r.deliver(res, instance, registration{service: service, port: port, txt: txt})
+	}
+}
+
+func (r *Registry) unregister(instance, service, domain string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.services[serviceKey(service, domain)], instance)
+}
+
+// resolve subscribes entries to every instance already registered for
+// service.domain, and to every future registration, until ctx is done. The
+// subscription is removed from the registry once ctx is done so long-lived
+// registries don't accumulate dead resolvers.
+func (r *Registry) resolve(ctx context.Context, instance string, entries chan mdns.LANPeerEvent, service, domain string) {
+	k := serviceKey(service, domain)
+	res := &resolver{instance: instance, ctx: ctx, entries: entries}
+
+	r.mu.Lock()
+	existing := make(map[string]registration, len(r.services[k]))
+	for name, reg := range r.services[k] {
+		existing[name] = reg
+	}
+	r.resolvers[k] = append(r.resolvers[k], res)
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.removeResolver(k, res)
+	}()
+
+	for name, reg := range existing {
+		r.deliver(res, name, reg)
+	}
+}
+
+// ResolverCount returns the number of live resolver subscriptions for
+// service.domain. It exists for tests asserting that a cancelled
+// resolver is actually removed rather than accumulating forever.
+func (r *Registry) ResolverCount(service, domain string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.resolvers[serviceKey(service, domain)])
+}
+
+func (r *Registry) removeResolver(k string, target *resolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	resolvers := r.resolvers[k]
+	for i, res := range resolvers {
+		if res == target {
+			r.resolvers[k] = append(resolvers[:i], resolvers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *Registry) deliver(res *resolver, instance string, reg registration) {
+	if instance == res.instance {
+		return
+	}
+
+	r.mu.Lock()
+	reachable := r.reachable(res.instance, instance)
+	dropped := r.dropped()
+	jitter := r.jitter()
+	r.mu.Unlock()
+
+	if !reachable || dropped {
+		return
+	}
+
+	event := mdns.LANPeerEvent{Port: reg.port, TXT: reg.txt, Service: reg.service}
+	if jitter <= 0 {
+		select {
+		case res.entries <- event:
+		case <-res.ctx.Done():
+		}
+		return
+	}
+
+	// jitter simulates out-of-order arrival; deliver off the calling
+	// goroutine so other deliveries in this round aren't held up by it.
+	go func() {
+		select {
+		case <-time.After(jitter):
+		case <-res.ctx.Done():
+			return
+		}
+
+		select {
+		case res.entries <- event:
+		case <-res.ctx.Done():
+		}
+	}()
+}
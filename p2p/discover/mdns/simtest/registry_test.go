@@ -0,0 +1,207 @@
+package simtest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytom/p2p/discover/mdns"
+)
+
+const testTimeout = 2 * time.Second
+
+func recvN(t *testing.T, ch chan mdns.LANPeerEvent, n int) []mdns.LANPeerEvent {
+	t.Helper()
+
+	events := make([]mdns.LANPeerEvent, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for event %d/%d, got %v so far", i+1, n, events)
+		}
+	}
+	return events
+}
+
+func assertNoEvent(t *testing.T, ch chan mdns.LANPeerEvent) {
+	t.Helper()
+
+	select {
+	case e := <-ch:
+		t.Fatalf("expected no event, got %v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestRegistryDiscovery registers every resolver before any service, so
+// delivery order exactly follows registration order and the sequence of
+// LANPeerEvent values each subscriber sees is fully deterministic.
+func TestRegistryDiscovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewRegistry(0, 0, 1)
+	entriesA := make(chan mdns.LANPeerEvent, 8)
+	entriesB := make(chan mdns.LANPeerEvent, 8)
+	entriesC := make(chan mdns.LANPeerEvent, 8)
+
+	a := NewSimulatedProtocol(registry, "a")
+	b := NewSimulatedProtocol(registry, "b")
+	c := NewSimulatedProtocol(registry, "c")
+
+	for _, r := range []struct {
+		p       *SimulatedProtocol
+		entries chan mdns.LANPeerEvent
+	}{{a, entriesA}, {b, entriesB}, {c, entriesC}} {
+		if err := r.p.RegisterResolver(ctx, r.entries, "p2p", "local", nil, mdns.AddressFamilyBoth); err != nil {
+			t.Fatalf("RegisterResolver: %v", err)
+		}
+	}
+
+	for i, p := range []*SimulatedProtocol{a, b, c} {
+		spec := mdns.ServiceSpec{Name: "p2p", Port: i, TXT: map[string]string{"network": "mainnet"}}
+		if err := p.RegisterService(ctx, p.instance, spec, "local", nil, mdns.AddressFamilyBoth); err != nil {
+			t.Fatalf("RegisterService: %v", err)
+		}
+	}
+
+	want := map[chan mdns.LANPeerEvent][]int{
+		entriesA: {1, 2}, // b, c
+		entriesB: {0, 2}, // a, c
+		entriesC: {0, 1}, // a, b
+	}
+	for ch, ports := range want {
+		events := recvN(t, ch, len(ports))
+		var got []int
+		for _, e := range events {
+			got = append(got, e.Port)
+			if e.Service != "p2p" {
+				t.Errorf("event %v has Service %q, want p2p", e, e.Service)
+			}
+		}
+		if !reflect.DeepEqual(got, ports) {
+			t.Errorf("got ports %v, want %v", got, ports)
+		}
+	}
+}
+
+// TestRegistryPartition verifies that instances in different named
+// partitions stop discovering each other, as if a router dropped the
+// mDNS multicast between them.
+func TestRegistryPartition(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewRegistry(0, 0, 2)
+	registry.Partition("a", "lan1")
+	registry.Partition("b", "lan1")
+	registry.Partition("c", "lan2")
+
+	entriesA := make(chan mdns.LANPeerEvent, 8)
+	entriesB := make(chan mdns.LANPeerEvent, 8)
+	entriesC := make(chan mdns.LANPeerEvent, 8)
+
+	a := NewSimulatedProtocol(registry, "a")
+	b := NewSimulatedProtocol(registry, "b")
+	c := NewSimulatedProtocol(registry, "c")
+
+	for _, r := range []struct {
+		p       *SimulatedProtocol
+		entries chan mdns.LANPeerEvent
+	}{{a, entriesA}, {b, entriesB}, {c, entriesC}} {
+		if err := r.p.RegisterResolver(ctx, r.entries, "p2p", "local", nil, mdns.AddressFamilyBoth); err != nil {
+			t.Fatalf("RegisterResolver: %v", err)
+		}
+	}
+
+	for i, p := range []*SimulatedProtocol{a, b, c} {
+		spec := mdns.ServiceSpec{Name: "p2p", Port: i}
+		if err := p.RegisterService(ctx, p.instance, spec, "local", nil, mdns.AddressFamilyBoth); err != nil {
+			t.Fatalf("RegisterService: %v", err)
+		}
+	}
+
+	// a and b are in the same partition and discover each other.
+	recvN(t, entriesA, 1)
+	recvN(t, entriesB, 1)
+	// c is in a different partition and discovers nobody; nobody discovers c.
+	assertNoEvent(t, entriesC)
+	assertNoEvent(t, entriesA)
+	assertNoEvent(t, entriesB)
+}
+
+// TestRegistryMultiService verifies that a node advertising more than
+// one service (e.g. the base p2p port plus an auxiliary RPC service) is
+// resolved independently per service, tagged with the right Service name.
+func TestRegistryMultiService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := NewRegistry(0, 0, 3)
+	a := NewSimulatedProtocol(registry, "a")
+	b := NewSimulatedProtocol(registry, "b")
+
+	p2pEntries := make(chan mdns.LANPeerEvent, 8)
+	rpcEntries := make(chan mdns.LANPeerEvent, 8)
+	if err := b.RegisterResolver(ctx, p2pEntries, "p2p", "local", nil, mdns.AddressFamilyBoth); err != nil {
+		t.Fatalf("RegisterResolver(p2p): %v", err)
+	}
+	if err := b.RegisterResolver(ctx, rpcEntries, "rpc", "local", nil, mdns.AddressFamilyBoth); err != nil {
+		t.Fatalf("RegisterResolver(rpc): %v", err)
+	}
+
+	if err := a.RegisterService(ctx, "a", mdns.ServiceSpec{Name: "p2p", Port: 46656}, "local", nil, mdns.AddressFamilyBoth); err != nil {
+		t.Fatalf("RegisterService(p2p): %v", err)
+	}
+	if err := a.RegisterService(ctx, "a", mdns.ServiceSpec{Name: "rpc", Port: 9888}, "local", nil, mdns.AddressFamilyBoth); err != nil {
+		t.Fatalf("RegisterService(rpc): %v", err)
+	}
+
+	p2pEvent := recvN(t, p2pEntries, 1)[0]
+	if p2pEvent.Port != 46656 || p2pEvent.Service != "p2p" {
+		t.Errorf("got p2p event %v, want Port 46656 Service p2p", p2pEvent)
+	}
+
+	rpcEvent := recvN(t, rpcEntries, 1)[0]
+	if rpcEvent.Port != 9888 || rpcEvent.Service != "rpc" {
+		t.Errorf("got rpc event %v, want Port 9888 Service rpc", rpcEvent)
+	}
+
+	// each resolver only ever sees its own service.
+	assertNoEvent(t, p2pEntries)
+	assertNoEvent(t, rpcEntries)
+}
+
+// TestRegistryResolverCleanup verifies a cancelled resolver is removed
+// from the Registry rather than accumulating forever.
+func TestRegistryResolverCleanup(t *testing.T) {
+	registry := NewRegistry(0, 0, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a := NewSimulatedProtocol(registry, "a")
+	entries := make(chan mdns.LANPeerEvent, 1)
+	if err := a.RegisterResolver(ctx, entries, "p2p", "local", nil, mdns.AddressFamilyBoth); err != nil {
+		t.Fatalf("RegisterResolver: %v", err)
+	}
+
+	if got := registry.ResolverCount("p2p", "local"); got != 1 {
+		t.Fatalf("ResolverCount before cancel = %d, want 1", got)
+	}
+
+	cancel()
+
+	deadline := time.After(testTimeout)
+	for {
+		if registry.ResolverCount("p2p", "local") == 0 {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("resolver was not removed from the registry after its context was cancelled")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}